@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// jwtHeaderB64 is the base64url encoding of the fixed JWT header we emit:
+// {"alg":"HS256","typ":"JWT"}.
+var jwtHeaderB64 = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// passwordHash hashes TODO_PASSWORD so it can be embedded in the token
+// payload without storing the password itself.
+func passwordHash(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// signToken issues a JWT signed with HMAC-SHA256 over the configured
+// password. The payload embeds a hash of the password so tokens signed
+// under an old TODO_PASSWORD stop validating once it changes.
+func signToken(password string) (string, error) {
+	payload, err := json.Marshal(map[string]string{"password_hash": passwordHash(password)})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal token payload: %v", err)
+	}
+
+	signingInput := jwtHeaderB64 + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write([]byte(signingInput))
+	signature := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return signingInput + "." + signature, nil
+}
+
+// verifyToken checks a JWT's signature and embedded password hash against
+// the currently configured password.
+func verifyToken(token, password string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write([]byte(signingInput))
+	expectedSig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expectedSig), []byte(parts[2])) {
+		return false
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	var payload struct {
+		PasswordHash string `json:"password_hash"`
+	}
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return false
+	}
+
+	return payload.PasswordHash == passwordHash(password)
+}
+
+// signInHandler checks the posted password against TODO_PASSWORD and, on
+// success, returns a signed JWT to be stored as a cookie.
+func signInHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error": "Method Not Allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var creds struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Error decoding JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	expected := os.Getenv("TODO_PASSWORD")
+	if !hmac.Equal([]byte(creds.Password), []byte(expected)) {
+		http.Error(w, `{"error": "Неверный пароль"}`, http.StatusUnauthorized)
+		return
+	}
+
+	token, err := signToken(expected)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to sign token: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
+
+// authMiddleware gates a handler behind a JWT cookie check. It is a no-op
+// when TODO_PASSWORD is unset, so auth is opt-in.
+func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		password := os.Getenv("TODO_PASSWORD")
+		if password == "" {
+			next(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie("token")
+		if err != nil || !verifyToken(cookie.Value, password) {
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Authentification required"})
+			return
+		}
+
+		next(w, r)
+	}
+}
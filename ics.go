@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Artem-Sergeevi4/go_final_project/repeat"
+)
+
+// icsWeekdayNames maps our 1..7 (Mon..Sun) weekday encoding to RFC 5545 BYDAY tokens.
+var icsWeekdayNames = map[int]string{
+	1: "MO", 2: "TU", 3: "WE", 4: "TH", 5: "FR", 6: "SA", 7: "SU",
+}
+
+var icsWeekdayNums = map[string]int{
+	"MO": 1, "TU": 2, "WE": 3, "TH": 4, "FR": 5, "SA": 6, "SU": 7,
+}
+
+// repeatToRRule translates our native repeat string into an RFC 5545 RRULE line.
+func repeatToRRule(repeatStr string) (string, error) {
+	switch {
+	case repeatStr == "":
+		return "", nil
+	case repeatStr == "y":
+		return "RRULE:FREQ=YEARLY", nil
+	case strings.HasPrefix(repeatStr, "d "):
+		var days int
+		if _, err := fmt.Sscanf(repeatStr, "d %d", &days); err != nil || days <= 0 || days > 400 {
+			return "", fmt.Errorf("invalid repeat rule: %v", repeatStr)
+		}
+		return fmt.Sprintf("RRULE:FREQ=DAILY;INTERVAL=%d", days), nil
+	case strings.HasPrefix(repeatStr, "w "):
+		parts := strings.Split(strings.TrimPrefix(repeatStr, "w "), ",")
+		names := make([]string, 0, len(parts))
+		for _, p := range parts {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 1 || n > 7 {
+				return "", fmt.Errorf("invalid repeat rule: %v", repeatStr)
+			}
+			names = append(names, icsWeekdayNames[n])
+		}
+		return "RRULE:FREQ=WEEKLY;BYDAY=" + strings.Join(names, ","), nil
+	case strings.HasPrefix(repeatStr, "m "):
+		days, months, err := repeat.ParseMonthlyFields(repeatStr)
+		if err != nil {
+			return "", err
+		}
+		dayStrs := make([]string, len(days))
+		for i, d := range days {
+			dayStrs[i] = strconv.Itoa(d)
+		}
+		rrule := "RRULE:FREQ=MONTHLY;BYMONTHDAY=" + strings.Join(dayStrs, ",")
+		if len(months) > 0 {
+			monthStrs := make([]string, len(months))
+			for i, m := range months {
+				monthStrs[i] = strconv.Itoa(m)
+			}
+			rrule += ";BYMONTH=" + strings.Join(monthStrs, ",")
+		}
+		return rrule, nil
+	default:
+		return "", fmt.Errorf("unsupported repeat rule: %v", repeatStr)
+	}
+}
+
+// rruleToRepeat translates an RFC 5545 RRULE line back into our native repeat string.
+func rruleToRepeat(rrule string) (string, error) {
+	rrule = strings.TrimPrefix(strings.TrimSpace(rrule), "RRULE:")
+	if rrule == "" {
+		return "", nil
+	}
+
+	fields := map[string]string{}
+	for _, part := range strings.Split(rrule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+
+	switch fields["FREQ"] {
+	case "YEARLY":
+		if _, ok := fields["BYMONTHDAY"]; ok {
+			return "", fmt.Errorf("unsupported BYMONTHDAY in rule: %v", rrule)
+		}
+		return "y", nil
+	case "DAILY":
+		if _, ok := fields["BYMONTHDAY"]; ok {
+			return "", fmt.Errorf("unsupported BYMONTHDAY in rule: %v", rrule)
+		}
+		interval := 1
+		if iv, ok := fields["INTERVAL"]; ok {
+			n, err := strconv.Atoi(iv)
+			if err != nil || n <= 0 {
+				return "", fmt.Errorf("invalid INTERVAL in rule: %v", rrule)
+			}
+			interval = n
+		}
+		return fmt.Sprintf("d %d", interval), nil
+	case "WEEKLY":
+		if _, ok := fields["BYMONTHDAY"]; ok {
+			return "", fmt.Errorf("unsupported BYMONTHDAY in rule: %v", rrule)
+		}
+		byday, ok := fields["BYDAY"]
+		if !ok {
+			return "", fmt.Errorf("missing BYDAY in rule: %v", rrule)
+		}
+		names := strings.Split(byday, ",")
+		nums := make([]string, 0, len(names))
+		for _, nm := range names {
+			n, ok := icsWeekdayNums[nm]
+			if !ok {
+				return "", fmt.Errorf("unsupported BYDAY value %q in rule: %v", nm, rrule)
+			}
+			nums = append(nums, strconv.Itoa(n))
+		}
+		return "w " + strings.Join(nums, ","), nil
+	case "MONTHLY":
+		bymonthday, ok := fields["BYMONTHDAY"]
+		if !ok {
+			return "", fmt.Errorf("missing BYMONTHDAY in rule: %v", rrule)
+		}
+		for _, ds := range strings.Split(bymonthday, ",") {
+			n, err := strconv.Atoi(ds)
+			if err != nil || n == 0 || n < -2 || n > 31 {
+				return "", fmt.Errorf("unsupported BYMONTHDAY value %q in rule: %v", ds, rrule)
+			}
+		}
+
+		repeat := "m " + bymonthday
+		if bymonth, ok := fields["BYMONTH"]; ok {
+			for _, ms := range strings.Split(bymonth, ",") {
+				n, err := strconv.Atoi(ms)
+				if err != nil || n < 1 || n > 12 {
+					return "", fmt.Errorf("unsupported BYMONTH value %q in rule: %v", ms, rrule)
+				}
+			}
+			repeat += " " + bymonth
+		}
+		return repeat, nil
+	default:
+		return "", fmt.Errorf("unsupported FREQ %q in rule: %v", fields["FREQ"], rrule)
+	}
+}
+
+// icsEscape escapes text values per RFC 5545 section 3.3.11.
+func icsEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `,`, `\,`, `;`, `\;`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// icsUnescape reverses icsEscape.
+func icsUnescape(s string) string {
+	r := strings.NewReplacer(`\n`, "\n", `\;`, ";", `\,`, ",", `\\`, `\`)
+	return r.Replace(s)
+}
+
+// icsEvent is a single parsed VEVENT block.
+type icsEvent struct {
+	UID         string
+	DTStart     string
+	Summary     string
+	Description string
+	RRule       string
+}
+
+// unfoldICSLines reverses RFC 5545 line folding: continuation lines start with
+// a space or tab and must be joined onto the previous line.
+func unfoldICSLines(data []byte) []string {
+	raw := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	var lines []string
+	for _, line := range raw {
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseICS extracts VEVENT blocks from an .ics payload.
+func parseICS(data []byte) ([]icsEvent, error) {
+	var events []icsEvent
+	var cur *icsEvent
+
+	for _, line := range unfoldICSLines(data) {
+		switch {
+		case line == "BEGIN:VEVENT":
+			cur = &icsEvent{}
+		case line == "END:VEVENT":
+			if cur == nil {
+				return nil, fmt.Errorf("unmatched END:VEVENT")
+			}
+			events = append(events, *cur)
+			cur = nil
+		case cur == nil:
+			continue
+		case strings.HasPrefix(line, "UID:"):
+			cur.UID = strings.TrimPrefix(line, "UID:")
+		case strings.HasPrefix(line, "DTSTART"):
+			if idx := strings.LastIndex(line, ":"); idx != -1 {
+				cur.DTStart = line[idx+1:]
+			}
+		case strings.HasPrefix(line, "SUMMARY:"):
+			cur.Summary = icsUnescape(strings.TrimPrefix(line, "SUMMARY:"))
+		case strings.HasPrefix(line, "DESCRIPTION:"):
+			cur.Description = icsUnescape(strings.TrimPrefix(line, "DESCRIPTION:"))
+		case strings.HasPrefix(line, "RRULE:"):
+			cur.RRule = line
+		}
+	}
+
+	if cur != nil {
+		return nil, fmt.Errorf("unterminated VEVENT")
+	}
+
+	return events, nil
+}
+
+// icsHandler dispatches GET (export) and POST (import) on /api/tasks/ics.
+func icsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		icsExportHandler(w, r)
+	case http.MethodPost:
+		icsImportHandler(w, r)
+	default:
+		http.Error(w, `{"error": "Method Not Allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// icsExportHandler serves every task as a text/calendar VEVENT stream.
+func icsExportHandler(w http.ResponseWriter, r *http.Request) {
+	tasks, _, err := store.ListTasks(TaskFilter{})
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to query tasks: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\n")
+	b.WriteString("VERSION:2.0\r\n")
+	b.WriteString("PRODID:-//go_final_project//scheduler//EN\r\n")
+
+	for _, task := range tasks {
+		rrule, err := repeatToRRule(task.Repeat)
+		if err != nil {
+			// Export the task without recurrence rather than failing the whole
+			// export, but make sure the drop is visible to operators.
+			log.Printf("ics export: task=%d repeat=%q dropped RRULE: %v", task.ID, task.Repeat, err)
+			rrule = ""
+		}
+
+		b.WriteString("BEGIN:VEVENT\r\n")
+		fmt.Fprintf(&b, "UID:%d\r\n", task.ID)
+		fmt.Fprintf(&b, "DTSTART;VALUE=DATE:%s\r\n", task.Date)
+		fmt.Fprintf(&b, "SUMMARY:%s\r\n", icsEscape(task.Title))
+		if task.Comment != "" {
+			fmt.Fprintf(&b, "DESCRIPTION:%s\r\n", icsEscape(task.Comment))
+		}
+		if rrule != "" {
+			fmt.Fprintf(&b, "%s\r\n", rrule)
+		}
+		b.WriteString("END:VEVENT\r\n")
+	}
+
+	b.WriteString("END:VCALENDAR\r\n")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="tasks.ics"`)
+	w.Write([]byte(b.String()))
+}
+
+// normalizeICSDate validates an iCalendar DTSTART value and returns it in
+// dateFormat. Both the VALUE=DATE form (YYYYMMDD) and the datetime form
+// (YYYYMMDDTHHMMSS[Z]) are accepted; only the date portion is kept since our
+// model has no time-of-day concept.
+func normalizeICSDate(dtstart string) (string, error) {
+	datePart := dtstart
+	if idx := strings.Index(dtstart, "T"); idx != -1 {
+		datePart = dtstart[:idx]
+	}
+	d, err := time.Parse(dateFormat, datePart)
+	if err != nil {
+		return "", fmt.Errorf("invalid DTSTART: %q", dtstart)
+	}
+	return d.Format(dateFormat), nil
+}
+
+// icsImportHandler bulk-imports VEVENTs from an uploaded .ics file.
+func icsImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error": "Method Not Allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to read body: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	events, err := parseICS(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid .ics file: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	type pendingTask struct {
+		task Task
+	}
+
+	var pending []pendingTask
+	var badUIDs []string
+
+	for _, ev := range events {
+		repeat, err := rruleToRepeat(ev.RRule)
+		if err != nil {
+			badUIDs = append(badUIDs, ev.UID)
+			continue
+		}
+
+		date, err := normalizeICSDate(ev.DTStart)
+		if err != nil {
+			badUIDs = append(badUIDs, ev.UID)
+			continue
+		}
+
+		if strings.TrimSpace(ev.Summary) == "" {
+			badUIDs = append(badUIDs, ev.UID)
+			continue
+		}
+
+		pending = append(pending, pendingTask{task: Task{
+			Date:    date,
+			Title:   ev.Summary,
+			Comment: ev.Description,
+			Repeat:  repeat,
+		}})
+	}
+
+	if len(badUIDs) > 0 {
+		http.Error(w, fmt.Sprintf(`{"error": "Unsupported or invalid VEVENT for UIDs: %s"}`, strings.Join(badUIDs, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	ids := make([]int64, 0, len(pending))
+	for _, p := range pending {
+		id, err := store.AddTask(p.task)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to insert task: %v"}`, err), http.StatusInternalServerError)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{"imported": len(ids)})
+}
@@ -6,13 +6,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3" // Импортируем драйвер sqlite3
+	"github.com/Artem-Sergeevi4/go_final_project/repeat"
 )
 
 const dateFormat = "20060102"
@@ -25,92 +22,6 @@ type Task struct {
 	Repeat  string `json:"repeat,omitempty"`
 }
 
-var db *sql.DB
-
-func initDB() error {
-	// Получаем текущий рабочий каталог
-	appPath, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current working directory: %v", err)
-	}
-
-	// Определяем полный путь к файлу базы данных
-	dbFile := filepath.Join(appPath, "scheduler.db")
-
-	// Открываем или создаем базу данных
-	db, err = sql.Open("sqlite3", dbFile)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %v", err)
-	}
-
-	// Проверяем, существует ли таблица scheduler
-	_, err = db.Exec(`
-        CREATE TABLE IF NOT EXISTS scheduler (
-            id INTEGER PRIMARY KEY AUTOINCREMENT,
-            date TEXT NOT NULL,
-            title TEXT NOT NULL,
-            comment TEXT,
-            repeat TEXT
-        );
-        CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date);
-    `)
-	if err != nil {
-		return fmt.Errorf("failed to create table: %v", err)
-	}
-
-	log.Println("Database initialized successfully.")
-	return nil
-}
-
-func NextDate(now time.Time, date string, repeat string) (string, error) {
-	d, err := time.Parse(dateFormat, date)
-	if err != nil {
-		return "", fmt.Errorf("invalid date format: %v", err)
-	}
-
-	switch {
-	case repeat == "":
-		return "", fmt.Errorf("repeat rule is empty")
-	case repeat == "y":
-		d = d.AddDate(1, 0, 0)
-		for d.Format(dateFormat) <= now.Format(dateFormat) {
-			d = d.AddDate(1, 0, 0)
-		}
-		return d.Format(dateFormat), nil
-	case strings.HasPrefix(repeat, "d "):
-		var days int
-		_, err := fmt.Sscanf(repeat, "d %d", &days)
-		if err != nil || days <= 0 || days > 400 {
-			return "", fmt.Errorf("invalid repeat rule: %v", repeat)
-		}
-		d = d.AddDate(0, 0, days)
-		for d.Format(dateFormat) <= now.Format(dateFormat) {
-			d = d.AddDate(0, 0, days)
-		}
-		return d.Format(dateFormat), nil
-	case strings.HasPrefix(repeat, "w "):
-		var daysOfWeek string
-		_, err := fmt.Sscanf(repeat, "w %s", &daysOfWeek)
-		if err != nil {
-			return "", fmt.Errorf("invalid repeat rule: %v", repeat)
-		}
-		days := strings.Split(daysOfWeek, ",")
-		for _, day := range days {
-			dayInt, err := strconv.Atoi(day)
-			if err != nil || dayInt < 1 || dayInt > 7 {
-				return "", fmt.Errorf("invalid repeat rule: %v", repeat)
-			}
-		}
-		d = d.AddDate(0, 0, 1)
-		for d.Format(dateFormat) <= now.Format(dateFormat) {
-			d = d.AddDate(0, 0, 1)
-		}
-		return d.Format(dateFormat), nil
-	default:
-		return "", fmt.Errorf("unsupported repeat rule: %v", repeat)
-	}
-}
-
 func nextDateHandler(w http.ResponseWriter, r *http.Request) {
 	nowStr := r.FormValue("now")
 	dateStr := r.FormValue("date")
@@ -122,7 +33,7 @@ func nextDateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	nextDate, err := NextDate(now, dateStr, repeatStr)
+	nextDate, err := repeat.NextDate(now, dateStr, repeatStr)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -164,7 +75,7 @@ func addTaskHandler(w http.ResponseWriter, r *http.Request) {
 			if task.Repeat == "" {
 				task.Date = now.Format(dateFormat)
 			} else {
-				nextDate, err := NextDate(now, task.Date, task.Repeat)
+				nextDate, err := repeat.NextDate(now, task.Date, task.Repeat)
 				if err != nil {
 					http.Error(w, fmt.Sprintf(`{"error": "Invalid repeat rule: %v"}`, err), http.StatusBadRequest)
 					return
@@ -174,55 +85,70 @@ func addTaskHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	query := `INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`
-	res, err := db.Exec(query, task.Date, task.Title, task.Comment, task.Repeat)
+	id, err := store.AddTask(task)
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error": "Failed to insert task: %v"}`, err), http.StatusInternalServerError)
 		return
 	}
 
-	id, err := res.LastInsertId()
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error": "Failed to retrieve last insert ID: %v"}`, err), http.StatusInternalServerError)
-		return
-	}
-
 	response := map[string]interface{}{"id": strconv.FormatInt(id, 10)}
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	json.NewEncoder(w).Encode(response)
 }
 
+const (
+	defaultTasksLimit = 50
+	maxTasksLimit     = 500
+)
+
+// parseSearchDate reports whether search is a dd.mm.yyyy date and, if so,
+// returns it in dateFormat.
+func parseSearchDate(search string) (string, bool) {
+	d, err := time.Parse("02.01.2006", search)
+	if err != nil {
+		return "", false
+	}
+	return d.Format(dateFormat), true
+}
+
 func getTasksHandler(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query("SELECT id, date, title, comment, repeat FROM scheduler ORDER BY date ASC LIMIT 50")
+	limit := defaultTasksLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxTasksLimit {
+		limit = maxTasksLimit
+	}
+
+	offset := 0
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	filter := TaskFilter{
+		Search: r.URL.Query().Get("search"),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	tasks, total, err := store.ListTasks(filter)
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error": "Failed to query tasks: %v"}`, err), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var tasks []map[string]string
-	for rows.Next() {
-		var task Task
-		err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
-		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error": "Failed to scan task: %v"}`, err), http.StatusInternalServerError)
-			return
-		}
-		taskMap := map[string]string{
+	taskMaps := make([]map[string]string, 0, len(tasks))
+	for _, task := range tasks {
+		taskMaps = append(taskMaps, map[string]string{
 			"id":      strconv.FormatInt(task.ID, 10),
 			"date":    task.Date,
 			"title":   task.Title,
 			"comment": task.Comment,
 			"repeat":  task.Repeat,
-		}
-		tasks = append(tasks, taskMap)
-	}
-
-	if tasks == nil {
-		tasks = []map[string]string{}
+		})
 	}
 
-	response := map[string][]map[string]string{"tasks": tasks}
+	response := map[string]interface{}{"tasks": taskMaps, "total": total}
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	json.NewEncoder(w).Encode(response)
 }
@@ -240,8 +166,7 @@ func getTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var task Task
-	err = db.QueryRow("SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?", id).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	task, err := store.GetTask(id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, `{"error": "Задача не найдена"}`, http.StatusNotFound)
@@ -293,7 +218,7 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 			if task.Repeat == "" {
 				task.Date = now.Format(dateFormat)
 			} else {
-				nextDate, err := NextDate(now, task.Date, task.Repeat)
+				nextDate, err := repeat.NextDate(now, task.Date, task.Repeat)
 				if err != nil {
 					http.Error(w, fmt.Sprintf(`{"error": "Invalid repeat rule: %v"}`, err), http.StatusBadRequest)
 					return
@@ -303,21 +228,13 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	query := `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?`
-	res, err := db.Exec(query, task.Date, task.Title, task.Comment, task.Repeat, task.ID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error": "Failed to update task: %v"}`, err), http.StatusInternalServerError)
-		return
-	}
-
-	rowsAffected, err := res.RowsAffected()
+	err = store.UpdateTask(task)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error": "Failed to retrieve rows affected: %v"}`, err), http.StatusInternalServerError)
-		return
-	}
-
-	if rowsAffected == 0 {
-		http.Error(w, `{"error": "Задача не найдена"}`, http.StatusNotFound)
+		if err == sql.ErrNoRows {
+			http.Error(w, `{"error": "Задача не найдена"}`, http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to update task: %v"}`, err), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -342,10 +259,17 @@ func main() {
 	}
 
 	http.Handle("/", http.FileServer(http.Dir("./web")))
-	http.HandleFunc("/api/nextdate", nextDateHandler)
-	http.HandleFunc("/api/task/add", addTaskHandler) // Маршрут для добавления задачи
-	http.HandleFunc("/api/tasks", getTasksHandler)   // Маршрут для получения списка задач
-	http.HandleFunc("/api/task", taskHandler)        // Маршрут для получения и обновления задачи
+	http.HandleFunc("/api/signin", signInHandler)
+	http.HandleFunc("/api/nextdate", authMiddleware(nextDateHandler))
+	http.HandleFunc("/api/task/add", authMiddleware(addTaskHandler))   // Маршрут для добавления задачи
+	http.HandleFunc("/api/tasks", authMiddleware(getTasksHandler))     // Маршрут для получения списка задач
+	http.HandleFunc("/api/task", authMiddleware(taskHandler))          // Маршрут для получения и обновления задачи
+	http.HandleFunc("/api/tasks/ics", authMiddleware(icsHandler))      // Экспорт/импорт задач в формате iCalendar
+	http.HandleFunc("/api/task/done", authMiddleware(doneTaskHandler)) // Завершение/перенос задачи
+
+	tick := envDuration("TODO_TICK", defaultTick)
+	ttl := envDuration("TODO_TTL", defaultTTL)
+	startCleanupScheduler(tick, ttl)
 
 	port := 7540
 	addr := ":" + strconv.Itoa(port)
@@ -0,0 +1,226 @@
+// Package repeat implements the task scheduler's native repeat-rule syntax
+// ("y", "d N", "w ...", "m ...") as a pluggable Engine, so new rule types can
+// be added without touching callers.
+package repeat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateFormat is the layout repeat rules and the dates they operate on are
+// expressed in.
+const DateFormat = "20060102"
+
+// maxNextDateIterations bounds the day-by-day search in Engine.NextDate so a
+// rule that can never match (e.g. "m 31 2") cannot hang the request.
+const maxNextDateIterations = 4000
+
+// Rule is a parsed repeat rule: it knows which calendar days it fires on.
+type Rule interface {
+	// Matches reports whether the rule fires on day t.
+	Matches(t time.Time) bool
+}
+
+// RuleYearly fires once a year on the same month/day as the task's start date.
+type RuleYearly struct {
+	Month time.Month
+	Day   int
+}
+
+func (r RuleYearly) Matches(t time.Time) bool {
+	return t.Month() == r.Month && t.Day() == r.Day
+}
+
+// RuleDaily fires every N days, counted from the task's start date.
+type RuleDaily struct {
+	Start time.Time
+	N     int
+}
+
+func (r RuleDaily) Matches(t time.Time) bool {
+	days := int(t.Sub(r.Start).Hours() / 24)
+	return days > 0 && days%r.N == 0
+}
+
+// RuleWeekly fires on the given weekdays.
+type RuleWeekly struct {
+	Days []time.Weekday
+}
+
+func (r RuleWeekly) Matches(t time.Time) bool {
+	for _, d := range r.Days {
+		if t.Weekday() == d {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleMonthly fires on the given days of the month, optionally restricted to
+// a set of months. A day of -1 means the last day of the month, -2 the
+// second-to-last.
+type RuleMonthly struct {
+	Days   []int
+	Months []int
+}
+
+func (r RuleMonthly) Matches(t time.Time) bool {
+	if len(r.Months) > 0 && !containsInt(r.Months, int(t.Month())) {
+		return false
+	}
+
+	lastDay := lastDayOfMonth(t)
+	for _, d := range r.Days {
+		switch {
+		case d > 0 && t.Day() == d:
+			return true
+		case d == -1 && t.Day() == lastDay:
+			return true
+		case d == -2 && t.Day() == lastDay-1:
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(xs []int, v int) bool {
+	for _, x := range xs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func lastDayOfMonth(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
+
+// weekdayFromOrdinal converts our 1..7 (Mon..Sun) encoding to time.Weekday.
+func weekdayFromOrdinal(n int) time.Weekday {
+	if n == 7 {
+		return time.Sunday
+	}
+	return time.Weekday(n)
+}
+
+// Engine parses and evaluates repeat rules. It holds no state; its methods
+// are the extension point new rule types get plugged into.
+type Engine struct{}
+
+// NewEngine returns a ready-to-use Engine.
+func NewEngine() *Engine {
+	return &Engine{}
+}
+
+// Parse parses our native repeat syntax into a Rule, anchored at start.
+func (e *Engine) Parse(start time.Time, repeat string) (Rule, error) {
+	switch {
+	case repeat == "y":
+		return RuleYearly{Month: start.Month(), Day: start.Day()}, nil
+	case strings.HasPrefix(repeat, "d "):
+		var n int
+		if _, err := fmt.Sscanf(repeat, "d %d", &n); err != nil || n <= 0 || n > 400 {
+			return nil, fmt.Errorf("invalid repeat rule: %v", repeat)
+		}
+		return RuleDaily{Start: start, N: n}, nil
+	case strings.HasPrefix(repeat, "w "):
+		parts := strings.Split(strings.TrimPrefix(repeat, "w "), ",")
+		days := make([]time.Weekday, 0, len(parts))
+		for _, p := range parts {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 1 || n > 7 {
+				return nil, fmt.Errorf("invalid repeat rule: %v", repeat)
+			}
+			days = append(days, weekdayFromOrdinal(n))
+		}
+		return RuleWeekly{Days: days}, nil
+	case strings.HasPrefix(repeat, "m "):
+		return e.parseMonthlyRule(repeat)
+	default:
+		return nil, fmt.Errorf("unsupported repeat rule: %v", repeat)
+	}
+}
+
+// parseMonthlyRule parses "m <days> [months]", e.g. "m -1" or "m 1,15 3,6,9".
+func (e *Engine) parseMonthlyRule(repeat string) (Rule, error) {
+	days, months, err := ParseMonthlyFields(repeat)
+	if err != nil {
+		return nil, err
+	}
+	return RuleMonthly{Days: days, Months: months}, nil
+}
+
+// ParseMonthlyFields parses the "<days> [months]" portion of an "m " repeat
+// rule into its day-of-month and optional month-restriction components. It
+// is exported so callers translating to/from other formats (e.g. ics.go's
+// RRULE mapping) stay in sync with what the engine actually accepts.
+func ParseMonthlyFields(repeat string) (days []int, months []int, err error) {
+	fields := strings.Fields(repeat)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, nil, fmt.Errorf("invalid repeat rule: %v", repeat)
+	}
+
+	for _, ds := range strings.Split(fields[1], ",") {
+		n, err := strconv.Atoi(ds)
+		if err != nil || n == 0 || n < -2 || n > 31 {
+			return nil, nil, fmt.Errorf("invalid repeat rule: %v", repeat)
+		}
+		days = append(days, n)
+	}
+
+	if len(fields) == 3 {
+		for _, ms := range strings.Split(fields[2], ",") {
+			n, err := strconv.Atoi(ms)
+			if err != nil || n < 1 || n > 12 {
+				return nil, nil, fmt.Errorf("invalid repeat rule: %v", repeat)
+			}
+			months = append(months, n)
+		}
+	}
+
+	return days, months, nil
+}
+
+// NextDate returns the next date (in DateFormat) that repeat fires on after
+// now, starting the search from date. It walks forward day by day, checking
+// the parsed rule's Matches predicate, so new rule types only need an entry
+// in Parse.
+func (e *Engine) NextDate(now time.Time, date string, repeat string) (string, error) {
+	start, err := time.Parse(DateFormat, date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date format: %v", err)
+	}
+
+	if repeat == "" {
+		return "", fmt.Errorf("repeat rule is empty")
+	}
+
+	rule, err := e.Parse(start, repeat)
+	if err != nil {
+		return "", err
+	}
+
+	d := start
+	for i := 0; i < maxNextDateIterations; i++ {
+		d = d.AddDate(0, 0, 1)
+		if rule.Matches(d) && d.Format(DateFormat) > now.Format(DateFormat) {
+			return d.Format(DateFormat), nil
+		}
+	}
+
+	return "", fmt.Errorf("repeat rule %q did not match within %d days", repeat, maxNextDateIterations)
+}
+
+// defaultEngine backs the package-level convenience functions below, for
+// callers that don't need to hold onto their own Engine.
+var defaultEngine = NewEngine()
+
+// NextDate is a package-level convenience wrapper around
+// defaultEngine.NextDate.
+func NextDate(now time.Time, date string, repeat string) (string, error) {
+	return defaultEngine.NextDate(now, date, repeat)
+}
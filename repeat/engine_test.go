@@ -0,0 +1,72 @@
+package repeat
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParseDate(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse(DateFormat, s)
+	if err != nil {
+		t.Fatalf("failed to parse date %q: %v", s, err)
+	}
+	return d
+}
+
+func TestNextDateYearlyLeapYear(t *testing.T) {
+	now := mustParseDate(t, "20240101")
+
+	next, err := NextDate(now, "20200229", "y")
+	if err != nil {
+		t.Fatalf("NextDate returned error: %v", err)
+	}
+	if next != "20240229" {
+		t.Errorf("expected 20240229, got %s", next)
+	}
+}
+
+func TestNextDateMonthlyLastDay(t *testing.T) {
+	now := mustParseDate(t, "20260201")
+
+	next, err := NextDate(now, "20260101", "m -1")
+	if err != nil {
+		t.Fatalf("NextDate returned error: %v", err)
+	}
+	if next != "20260228" {
+		t.Errorf("expected 20260228, got %s", next)
+	}
+}
+
+func TestNextDateWeekly(t *testing.T) {
+	now := mustParseDate(t, "20260101")
+
+	next, err := NextDate(now, "20260101", "w 1")
+	if err != nil {
+		t.Fatalf("NextDate returned error: %v", err)
+	}
+	if d, _ := time.Parse(DateFormat, next); d.Weekday() != time.Monday {
+		t.Errorf("expected a Monday, got %s (%s)", next, d.Weekday())
+	}
+}
+
+func TestNextDateMonthlyRestrictedToMonths(t *testing.T) {
+	now := mustParseDate(t, "20260101")
+
+	// Day 2 of the month, but only in March.
+	next, err := NextDate(now, "20260101", "m 2 3")
+	if err != nil {
+		t.Fatalf("NextDate returned error: %v", err)
+	}
+	if d, _ := time.Parse(DateFormat, next); d.Month() != time.March || d.Day() != 2 {
+		t.Errorf("expected March 2nd, got %s", next)
+	}
+}
+
+func TestNextDateInvalidRule(t *testing.T) {
+	now := mustParseDate(t, "20260101")
+
+	if _, err := NextDate(now, "20260101", "m 40"); err == nil {
+		t.Error("expected an error for an out-of-range day of month")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultTick and defaultTTL are used when TODO_TICK / TODO_TTL are unset.
+const (
+	defaultTick = time.Minute
+	defaultTTL  = 30 * 24 * time.Hour
+)
+
+// doneTaskHandler marks a task done: non-repeating tasks are deleted,
+// repeating tasks have their date advanced to the next occurrence.
+func doneTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error": "Method Not Allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		http.Error(w, `{"error": "Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, `{"error": "Неверный формат идентификатора"}`, http.StatusBadRequest)
+		return
+	}
+
+	deleted, oldDate, newDate, err := store.MarkDone(id, time.Now())
+	if err != nil {
+		var invalidRepeat *InvalidRepeatError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, `{"error": "Задача не найдена"}`, http.StatusNotFound)
+		case errors.As(err, &invalidRepeat):
+			http.Error(w, fmt.Sprintf(`{"error": "Invalid repeat rule: %v"}`, invalidRepeat.Err), http.StatusBadRequest)
+		default:
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to mark task done: %v"}`, err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if deleted {
+		log.Printf("scheduler: task=%d date=%s action=deleted", id, oldDate)
+	} else {
+		log.Printf("scheduler: task=%d old_date=%s new_date=%s action=advanced", id, oldDate, newDate)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
+
+// envDuration reads key as a count of seconds, falling back to def when the
+// env var is unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds <= 0 {
+		log.Printf("scheduler: invalid %s=%q, falling back to %s", key, v, def)
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// startCleanupScheduler launches a background goroutine that wakes every
+// tick and deletes non-repeating tasks whose date is older than ttl,
+// logging each decision so operators can audit the scheduler.
+func startCleanupScheduler(tick, ttl time.Duration) {
+	go func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for range ticker.C {
+			cleanupOverdueTasks(ttl)
+		}
+	}()
+}
+
+// cleanupOverdueTasks deletes non-repeating tasks whose date is older than
+// ttl relative to now. Each call handles at most maxTasksLimit of them,
+// oldest first; any remainder is left for later ticks.
+func cleanupOverdueTasks(ttl time.Duration) {
+	cutoff := time.Now().Add(-ttl).Format(dateFormat)
+
+	stale, _, err := store.ListTasks(TaskFilter{
+		Before:           cutoff,
+		NonRepeatingOnly: true,
+		Limit:            maxTasksLimit,
+	})
+	if err != nil {
+		log.Printf("scheduler: cleanup query failed: %v", err)
+		return
+	}
+
+	for _, task := range stale {
+		if err := store.DeleteTask(task.ID); err != nil {
+			log.Printf("scheduler: task=%d date=%s action=cleanup-failed error=%v", task.ID, task.Date, err)
+			continue
+		}
+		log.Printf("scheduler: task=%d old_date=%s action=cleanup-deleted", task.ID, task.Date)
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Artem-Sergeevi4/go_final_project/repeat"
+	_ "github.com/lib/pq"           // Драйвер PostgreSQL
+	_ "github.com/mattn/go-sqlite3" // Драйвер sqlite3
+)
+
+//go:embed migrations/sqlite.sql
+var sqliteMigration string
+
+//go:embed migrations/postgres.sql
+var postgresMigration string
+
+// TaskFilter narrows ListTasks. Search matches either an exact dd.mm.yyyy
+// date or a substring of title/comment. Before, when set, additionally
+// restricts results to dates strictly earlier than it (used by the cleanup
+// scheduler). A non-positive Limit means "no limit".
+type TaskFilter struct {
+	Search           string
+	Before           string
+	NonRepeatingOnly bool
+	Limit            int
+	Offset           int
+}
+
+// Store abstracts task persistence so handlers don't depend on a concrete
+// database driver.
+type Store interface {
+	AddTask(task Task) (int64, error)
+	GetTask(id int64) (Task, error)
+	UpdateTask(task Task) error
+	DeleteTask(id int64) error
+	ListTasks(filter TaskFilter) ([]Task, int, error)
+	MarkDone(id int64, now time.Time) (deleted bool, oldDate, newDate string, err error)
+}
+
+// store is the backend selected by initDB, used by every handler.
+var store Store
+
+// InvalidRepeatError wraps a NextDate failure inside MarkDone so callers can
+// tell a malformed repeat rule (client's fault) apart from a storage failure
+// (server's fault).
+type InvalidRepeatError struct{ Err error }
+
+func (e *InvalidRepeatError) Error() string { return e.Err.Error() }
+func (e *InvalidRepeatError) Unwrap() error { return e.Err }
+
+// taskFilterQuery builds the "WHERE ..." clause (and its args) shared by
+// both backends' ListTasks, parameterized over the LIKE operator since
+// that's the one piece of SQL that actually differs between them.
+func taskFilterQuery(filter TaskFilter, likeOp string) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	if filter.Search != "" {
+		if date, ok := parseSearchDate(filter.Search); ok {
+			conds = append(conds, "date = ?")
+			args = append(args, date)
+		} else {
+			conds = append(conds, fmt.Sprintf("(title %s ? OR comment %s ?)", likeOp, likeOp))
+			like := "%" + filter.Search + "%"
+			args = append(args, like, like)
+		}
+	}
+	if filter.Before != "" {
+		conds = append(conds, "date < ?")
+		args = append(args, filter.Before)
+	}
+	if filter.NonRepeatingOnly {
+		conds = append(conds, "repeat = ''")
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+	return where, args
+}
+
+// markDone implements the MarkDone contract in terms of a Store's other
+// methods, so sqliteStore and postgresStore can each delegate to it instead
+// of duplicating logic that has no backend-specific SQL of its own.
+func markDone(s Store, id int64, now time.Time) (bool, string, string, error) {
+	task, err := s.GetTask(id)
+	if err != nil {
+		return false, "", "", err
+	}
+	oldDate := task.Date
+
+	if task.Repeat == "" {
+		if err := s.DeleteTask(id); err != nil {
+			return false, "", "", err
+		}
+		return true, oldDate, "", nil
+	}
+
+	nextDate, err := repeat.NextDate(now, task.Date, task.Repeat)
+	if err != nil {
+		return false, "", "", &InvalidRepeatError{err}
+	}
+
+	task.Date = nextDate
+	if err := s.UpdateTask(task); err != nil {
+		return false, "", "", err
+	}
+	return false, oldDate, nextDate, nil
+}
+
+// sqliteStore implements Store over a sqlite3 database.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func (s *sqliteStore) AddTask(task Task) (int64, error) {
+	res, err := s.db.Exec(
+		`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`,
+		task.Date, task.Title, task.Comment, task.Repeat,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *sqliteStore) GetTask(id int64) (Task, error) {
+	var task Task
+	err := s.db.QueryRow(
+		`SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?`, id,
+	).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	return task, err
+}
+
+func (s *sqliteStore) UpdateTask(task Task) error {
+	res, err := s.db.Exec(
+		`UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?`,
+		task.Date, task.Title, task.Comment, task.Repeat, task.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *sqliteStore) DeleteTask(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	return err
+}
+
+func (s *sqliteStore) ListTasks(filter TaskFilter) ([]Task, int, error) {
+	where, args := taskFilterQuery(filter, "LIKE")
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM scheduler "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT id, date, title, comment, repeat FROM scheduler " + where + " ORDER BY date ASC, id ASC"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		var task Task
+		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
+			return nil, 0, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, total, rows.Err()
+}
+
+func (s *sqliteStore) MarkDone(id int64, now time.Time) (bool, string, string, error) {
+	return markDone(s, id, now)
+}
+
+// postgresStore implements Store over a PostgreSQL database. lib/pq doesn't
+// accept sqlite3-style `?` placeholders, so every query is rewritten to
+// `$N` via rebindPostgres before it's run.
+type postgresStore struct {
+	db *sql.DB
+}
+
+// rebindPostgres rewrites `?` placeholders to lib/pq's `$N` syntax.
+func rebindPostgres(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (s *postgresStore) AddTask(task Task) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(
+		rebindPostgres(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?) RETURNING id`),
+		task.Date, task.Title, task.Comment, task.Repeat,
+	).Scan(&id)
+	return id, err
+}
+
+func (s *postgresStore) GetTask(id int64) (Task, error) {
+	var task Task
+	err := s.db.QueryRow(
+		rebindPostgres(`SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?`), id,
+	).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	return task, err
+}
+
+func (s *postgresStore) UpdateTask(task Task) error {
+	res, err := s.db.Exec(
+		rebindPostgres(`UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?`),
+		task.Date, task.Title, task.Comment, task.Repeat, task.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *postgresStore) DeleteTask(id int64) error {
+	_, err := s.db.Exec(rebindPostgres(`DELETE FROM scheduler WHERE id = ?`), id)
+	return err
+}
+
+func (s *postgresStore) ListTasks(filter TaskFilter) ([]Task, int, error) {
+	// Postgres's LIKE is case-sensitive, unlike SQLite's ASCII-only LIKE, so
+	// ILIKE is needed to get the same "case-insensitive substring" behavior.
+	where, args := taskFilterQuery(filter, "ILIKE")
+
+	var total int
+	if err := s.db.QueryRow(rebindPostgres("SELECT COUNT(*) FROM scheduler "+where), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT id, date, title, comment, repeat FROM scheduler " + where + " ORDER BY date ASC, id ASC"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := s.db.Query(rebindPostgres(query), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		var task Task
+		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
+			return nil, 0, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, total, rows.Err()
+}
+
+func (s *postgresStore) MarkDone(id int64, now time.Time) (bool, string, string, error) {
+	return markDone(s, id, now)
+}
+
+func newSQLiteStore(dsn string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteMigration); err != nil {
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func newPostgresStore(dsn string) (*postgresStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(postgresMigration); err != nil {
+		return nil, err
+	}
+	return &postgresStore{db: db}, nil
+}
+
+// initDB selects the storage backend from TODO_DBDRIVER/TODO_DBDSN
+// (defaulting to a local sqlite3 file) and runs its migration.
+func initDB() error {
+	driver := os.Getenv("TODO_DBDRIVER")
+	if driver == "" {
+		driver = "sqlite3"
+	}
+
+	switch driver {
+	case "sqlite3":
+		dsn := os.Getenv("TODO_DBDSN")
+		if dsn == "" {
+			appPath, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+			dsn = filepath.Join(appPath, "scheduler.db")
+		}
+		s, err := newSQLiteStore(dsn)
+		if err != nil {
+			return fmt.Errorf("failed to open sqlite database: %v", err)
+		}
+		store = s
+	case "postgres":
+		dsn := os.Getenv("TODO_DBDSN")
+		if dsn == "" {
+			return fmt.Errorf("TODO_DBDSN is required for the postgres driver")
+		}
+		s, err := newPostgresStore(dsn)
+		if err != nil {
+			return fmt.Errorf("failed to open postgres database: %v", err)
+		}
+		store = s
+	default:
+		return fmt.Errorf("unsupported TODO_DBDRIVER: %v", driver)
+	}
+
+	log.Println("Database initialized successfully.")
+	return nil
+}